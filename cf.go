@@ -10,10 +10,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/flower-corp/lotusdb/index"
 	"github.com/flower-corp/lotusdb/logfile"
 	"github.com/flower-corp/lotusdb/memtable"
+	"github.com/flower-corp/lotusdb/metrics"
 	"github.com/flower-corp/lotusdb/util"
 	"github.com/flower-corp/lotusdb/vlog"
 )
@@ -37,7 +40,20 @@ type ColumnFamily struct {
 	indexer   index.Indexer
 	flushChn  chan *memtable.Memtable
 	opts      ColumnFamilyOptions
-	mu        sync.Mutex
+	seqNo      uint64 // Sequence number of the last applied write, bumped under mu.
+	gcRuns     int64  // Number of completed value log GC passes, bumped atomically.
+	putCount   int64  // Number of PutWithOptions calls, bumped atomically.
+	getCount   int64  // Number of Get calls, bumped atomically.
+	flushCount int64  // Number of memtables flushed by listenAndFlush, bumped atomically.
+	// openSnapshots counts live Snapshots, bumped atomically by NewSnapshot/
+	// Snapshot.Close. A snapshot pins old indexer/vlog state by holding a
+	// bbolt read transaction open, but nothing stops a concurrent GC pass
+	// from reclaiming a vlog location the snapshot still depends on once
+	// the live index has moved past it; RunValueLogGC defers its whole
+	// pass while this is non-zero rather than tracking which locations
+	// each snapshot actually touches.
+	openSnapshots int64
+	mu            sync.Mutex
 }
 
 // OpenColumnFamily open a new or existed column family.
@@ -71,15 +87,26 @@ func (db *LotusDB) OpenColumnFamily(ctx context.Context, opts ColumnFamilyOption
 		return nil, err
 	}
 
-	// create bptree indexer.
-	bptreeOpt := &index.BPTreeOptions{
-		IndexType:        index.BptreeBoltDB,
-		ColumnFamilyName: opts.CfName,
-		BucketName:       []byte(opts.CfName),
-		DirPath:          opts.DirPath,
-		BatchSize:        100000,
+	// create the indexer, BoltDB-backed B+tree by default, or an
+	// in-memory adaptive radix tree when the column family asks for it.
+	var indexOpts index.Options
+	switch opts.IndexerType {
+	case index.ArtRadixTree:
+		indexOpts = &index.ARTOptions{
+			IndexType:        index.ArtRadixTree,
+			ColumnFamilyName: opts.CfName,
+			DirPath:          opts.DirPath,
+		}
+	default:
+		indexOpts = &index.BPTreeOptions{
+			IndexType:        index.BptreeBoltDB,
+			ColumnFamilyName: opts.CfName,
+			BucketName:       []byte(opts.CfName),
+			DirPath:          opts.DirPath,
+			BatchSize:        100000,
+		}
 	}
-	indexer, err := index.NewIndexer(bptreeOpt)
+	indexer, err := index.NewIndexer(indexOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -99,6 +126,7 @@ func (db *LotusDB) OpenColumnFamily(ctx context.Context, opts ColumnFamilyOption
 	db.cfs[opts.CfName] = cf
 
 	go cf.listenAndFlush(ctx)
+	go cf.listenAndCompact(ctx)
 	return cf, nil
 }
 
@@ -111,8 +139,17 @@ func (cf *ColumnFamily) Put(key, value []byte) error {
 	return cf.PutWithOptions(key, value, nil)
 }
 
-// PutWithOptions put to current column family with options.
+// PutWithOptions put to current column family with options. Values at or
+// above opts.LargeValueThreshold are written to the value log immediately,
+// through its chunked FormatV2 path, and only a pointer is kept in the
+// memtable.
 func (cf *ColumnFamily) PutWithOptions(key, value []byte, opt *WriteOptions) error {
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&cf.putCount, 1)
+		metrics.ObservePutLatency(cf.opts.CfName, time.Since(start).Seconds())
+	}()
+
 	// waiting for enough memtable sapce to write.
 	if err := cf.waitMemSpace(); err != nil {
 		return err
@@ -124,19 +161,34 @@ func (cf *ColumnFamily) PutWithOptions(key, value []byte, opt *WriteOptions) err
 		memOpts.DisableWal = opt.DisableWal
 		memOpts.ExpiredAt = opt.ExpiredAt
 	}
-	if err := cf.activeMem.Put(key, value, memOpts); err != nil {
+	memOpts.Seq = cf.nextSeq()
+
+	cf.markStaleIfVlog(key)
+	stored, err := cf.stageValue(key, value)
+	if err != nil {
 		return err
 	}
+	walStart := time.Now()
+	if err := cf.activeMem.Put(key, stored, memOpts); err != nil {
+		return err
+	}
+	metrics.ObserveWalFsyncLatency(cf.opts.CfName, time.Since(walStart).Seconds())
 	return nil
 }
 
 // Get get from current column family.
 func (cf *ColumnFamily) Get(key []byte) ([]byte, error) {
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&cf.getCount, 1)
+		metrics.ObserveGetLatency(cf.opts.CfName, time.Since(start).Seconds())
+	}()
+
 	tables := cf.getMemtables()
 	// get from active and immutable memtables.
 	for _, mem := range tables {
-		if value := mem.Get(key); len(value) != 0 {
-			return value, nil
+		if raw := mem.Get(key); len(raw) != 0 {
+			return cf.resolveStoredValue(raw)
 		}
 	}
 
@@ -174,15 +226,63 @@ func (cf *ColumnFamily) DeleteWithOptions(key []byte, opt *WriteOptions) error {
 		memOpts.DisableWal = opt.DisableWal
 		memOpts.ExpiredAt = opt.ExpiredAt
 	}
+	memOpts.Seq = cf.nextSeq()
+	cf.markStaleIfVlog(key)
+	walStart := time.Now()
 	if err := cf.activeMem.Delete(key, memOpts); err != nil {
 		return err
 	}
+	metrics.ObserveWalFsyncLatency(cf.opts.CfName, time.Since(walStart).Seconds())
 	return nil
 }
 
-// Stat returns some statistics info of current column family.
-func (cf *ColumnFamily) Stat() error {
-	return nil
+// Name returns the column family's name, used to label its metrics.
+func (cf *ColumnFamily) Name() string {
+	return cf.opts.CfName
+}
+
+// Stat returns a point-in-time snapshot of the column family's internal
+// counters: memtable sizes, the immutable queue depth, put/get/flush/GC
+// counts, the indexer's bbolt transaction count (zero for an ART
+// indexer), and value log live/stale bytes summed over every segment;
+// per-fid figures are not reported. It backs the
+// metrics.Collector Prometheus integration, and can also be read
+// directly by anyone wanting the same visibility goleveldb/Pebble expose
+// through their own stats structs.
+func (cf *ColumnFamily) Stat() (*metrics.Stats, error) {
+	cf.mu.Lock()
+	tables := cf.getMemtablesLocked()
+	cf.mu.Unlock()
+
+	activeBytes := tables[0].Size()
+	var immuBytes int64
+	for _, mem := range tables[1:] {
+		immuBytes += mem.Size()
+	}
+
+	var boltTxCount int64
+	if bt, ok := cf.indexer.(interface{ TxCount() int64 }); ok {
+		boltTxCount = bt.TxCount()
+	}
+
+	var liveBytes, staleBytes int64
+	for _, st := range cf.vlog.Stats() {
+		liveBytes += st.LiveBytes
+		staleBytes += st.StaleBytes
+	}
+
+	return &metrics.Stats{
+		ActiveMemBytes: activeBytes,
+		ImmuMemCount:   len(tables) - 1,
+		ImmuMemBytes:   immuBytes,
+		FlushCount:     atomic.LoadInt64(&cf.flushCount),
+		PutCount:       atomic.LoadInt64(&cf.putCount),
+		GetCount:       atomic.LoadInt64(&cf.getCount),
+		BoltTxCount:    boltTxCount,
+		GCRuns:         atomic.LoadInt64(&cf.gcRuns),
+		VlogLiveBytes:  liveBytes,
+		VlogStaleBytes: staleBytes,
+	}, nil
 }
 
 func (cf *ColumnFamily) openMemtables() error {
@@ -257,7 +357,12 @@ func (cf *ColumnFamily) getMemtableType() memtable.TableType {
 func (cf *ColumnFamily) getMemtables() []*memtable.Memtable {
 	cf.mu.Lock()
 	defer cf.mu.Unlock()
+	return cf.getMemtablesLocked()
+}
 
+// getMemtablesLocked returns the active memtable followed by the immutable
+// ones, newest first. Callers must already hold cf.mu.
+func (cf *ColumnFamily) getMemtablesLocked() []*memtable.Memtable {
 	immuLen := len(cf.immuMems)
 	var tables = make([]*memtable.Memtable, immuLen+1)
 	tables[0] = cf.activeMem
@@ -267,6 +372,15 @@ func (cf *ColumnFamily) getMemtables() []*memtable.Memtable {
 	return tables
 }
 
+// nextSeq allocates the sequence number for the next write, used to give
+// batches an atomic commit point and snapshots a consistent cutoff.
+func (cf *ColumnFamily) nextSeq() uint64 {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	cf.seqNo++
+	return cf.seqNo
+}
+
 func (cf *ColumnFamily) trimOneImmuMem() {
 	cf.mu.Lock()
 	if len(cf.immuMems) > 1 {
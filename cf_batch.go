@@ -0,0 +1,167 @@
+package lotusdb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/flower-corp/lotusdb/memtable"
+	"github.com/flower-corp/lotusdb/metrics"
+	"github.com/flower-corp/lotusdb/vlog"
+)
+
+// ErrBatchCommitted is returned by Commit when it is called more than once
+// on the same Batch.
+var ErrBatchCommitted = errors.New("lotusdb: batch has already been committed")
+
+type batchRecordType int8
+
+const (
+	batchRecordPut batchRecordType = iota
+	batchRecordDelete
+)
+
+// batchRecord is one staged write. A Put at or above LargeValueThreshold is
+// written straight to the batch's staging vlog file as it's staged, so
+// value holds nothing and staged/fid/offset/size locate it there instead.
+type batchRecord struct {
+	key    []byte
+	value  []byte
+	staged bool
+	fid    uint32
+	offset int64
+	size   int64
+	typ    batchRecordType
+}
+
+// Batch stages a group of Put/Delete operations against a ColumnFamily so
+// they can be applied atomically on Commit: one sequence number and a
+// single memtable.PutBatch call covering every record, so a concurrent
+// reader either sees all of the batch's writes or none of them. Large
+// values are spilled to a private staging vlog file as they are staged
+// rather than held in records, bounding batch size by disk instead of RAM.
+// This mirrors goleveldb and Pebble's Batch type.
+type Batch struct {
+	cf        *ColumnFamily
+	opts      WriteOptions
+	records   []batchRecord
+	staging   *vlog.Staging
+	committed bool
+}
+
+// NewBatch creates an empty batch of writes for the column family.
+func (cf *ColumnFamily) NewBatch(opt *WriteOptions) *Batch {
+	b := &Batch{cf: cf}
+	if opt != nil {
+		b.opts = *opt
+	}
+	return b
+}
+
+// Put stages a key/value write. It has no effect on readers until Commit
+// succeeds. Values at or above LargeValueThreshold are written to the
+// batch's private staging vlog file immediately, rather than held in
+// memory until Commit.
+func (b *Batch) Put(key, value []byte) error {
+	if b.cf.opts.LargeValueThreshold > 0 && int64(len(value)) >= b.cf.opts.LargeValueThreshold {
+		if b.staging == nil {
+			st, err := b.cf.vlog.NewStaging()
+			if err != nil {
+				return err
+			}
+			b.staging = st
+		}
+		fid, offset, size, err := b.staging.Write(key, value)
+		if err != nil {
+			return err
+		}
+		b.records = append(b.records, batchRecord{key: key, staged: true, fid: fid, offset: offset, size: size, typ: batchRecordPut})
+		return nil
+	}
+	b.records = append(b.records, batchRecord{key: key, value: value, typ: batchRecordPut})
+	return nil
+}
+
+// Delete stages a key deletion. It has no effect until Commit succeeds.
+func (b *Batch) Delete(key []byte) {
+	b.records = append(b.records, batchRecord{key: key, typ: batchRecordDelete})
+}
+
+// Discard abandons every staged write without applying any of them,
+// including removing the batch's private staging file (if one was
+// opened) so none of its large values ever become visible to readers.
+func (b *Batch) Discard() error {
+	if b.staging != nil {
+		return b.staging.Discard()
+	}
+	return nil
+}
+
+// Commit applies every staged record under a single sequence number and a
+// single memtable.PutBatch call, so it either lands as a whole or not at
+// all: a failure partway through building the entries leaves the active
+// memtable untouched, and PutBatch itself is one WAL append rather than one
+// per record.
+func (b *Batch) Commit() error {
+	if b.committed {
+		return ErrBatchCommitted
+	}
+	if len(b.records) == 0 {
+		b.committed = true
+		return nil
+	}
+
+	if err := b.cf.waitMemSpace(); err != nil {
+		return err
+	}
+
+	// Link the staging file's records into the live segment set before
+	// anything in the batch becomes visible, so the fid/offset pairs
+	// already handed out by Staging.Write resolve once the memtable
+	// entries pointing at them land.
+	if b.staging != nil {
+		if err := b.staging.Commit(); err != nil {
+			return err
+		}
+	}
+
+	entries := make([]memtable.BatchEntry, len(b.records))
+	for i, rec := range b.records {
+		switch rec.typ {
+		case batchRecordPut:
+			var stored []byte
+			if rec.staged {
+				stored = encodeVlogPointer(rec.fid, rec.offset, rec.size)
+			} else {
+				var err error
+				stored, err = b.cf.stageValue(rec.key, rec.value)
+				if err != nil {
+					return err
+				}
+			}
+			entries[i] = memtable.BatchEntry{Key: rec.key, Value: stored, Type: memtable.EntryPut}
+		case batchRecordDelete:
+			entries[i] = memtable.BatchEntry{Key: rec.key, Type: memtable.EntryDelete}
+		}
+	}
+
+	memOpts := memtable.Options{
+		Sync:       b.opts.Sync,
+		DisableWal: b.opts.DisableWal,
+		ExpiredAt:  b.opts.ExpiredAt,
+		Seq:        b.cf.nextSeq(),
+	}
+	walStart := time.Now()
+	if err := b.cf.activeMem.PutBatch(entries, memOpts); err != nil {
+		return err
+	}
+	metrics.ObserveWalFsyncLatency(b.cf.opts.CfName, time.Since(walStart).Seconds())
+
+	// Only now that every record is durably visible in the memtable do we
+	// retire the index locations it superseded.
+	for _, rec := range b.records {
+		b.cf.markStaleIfVlog(rec.key)
+	}
+
+	b.committed = true
+	return nil
+}
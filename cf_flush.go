@@ -0,0 +1,87 @@
+package lotusdb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/flower-corp/lotusdb/index"
+	"github.com/flower-corp/lotusdb/memtable"
+	"github.com/flower-corp/lotusdb/metrics"
+)
+
+// listenAndFlush is the background flush loop started alongside
+// listenAndCompact. It drains flushChn, writing each immutable memtable's
+// entries into the indexer and dropping the memtable once it has been
+// fully persisted there.
+func (cf *ColumnFamily) listenAndFlush(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case table, ok := <-cf.flushChn:
+			if !ok {
+				return
+			}
+			start := time.Now()
+			if err := cf.flushMemtable(table); err != nil {
+				// The receive above already took table off flushChn, so
+				// it has to be pushed back on explicitly or it's lost for
+				// good; done from a separate goroutine since flushChn may
+				// be full and this loop needs to keep draining the rest
+				// of the queue in the meantime.
+				go func(t *memtable.Memtable) {
+					select {
+					case cf.flushChn <- t:
+					case <-ctx.Done():
+					}
+				}(table)
+				continue
+			}
+			cf.trimOneImmuMem()
+			atomic.AddInt64(&cf.flushCount, 1)
+			metrics.ObserveFlushLatency(cf.opts.CfName, time.Since(start).Seconds())
+		}
+	}
+}
+
+// flushMemtable writes every entry in table into the indexer, resolving
+// each entry's stageValue-encoded tag into the IndexerMeta shape PutBatch
+// expects, and deleting any key table recorded a tombstone for.
+func (cf *ColumnFamily) flushMemtable(table *memtable.Memtable) error {
+	iter, err := table.NewIterator()
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	var puts []index.IndexerKvnode
+	var deletes [][]byte
+	for key, raw := iter.Seek(nil); key != nil; key, raw = iter.Next() {
+		if len(raw) == 0 {
+			deletes = append(deletes, key)
+			continue
+		}
+
+		meta := &index.IndexerMeta{}
+		switch valueTag(raw[0]) {
+		case valueInline:
+			meta.Value = raw[1:]
+		case valueVlogPointer:
+			meta.Fid, meta.Offset, meta.Size = decodeVlogPointer(raw[1:])
+		}
+		puts = append(puts, index.IndexerKvnode{Key: key, Meta: meta})
+	}
+
+	if len(puts) > 0 {
+		if _, err := cf.indexer.PutBatch(puts); err != nil {
+			return err
+		}
+	}
+	for _, key := range deletes {
+		if err := cf.indexer.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
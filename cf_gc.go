@@ -0,0 +1,227 @@
+package lotusdb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/flower-corp/lotusdb/index"
+	"github.com/flower-corp/lotusdb/metrics"
+)
+
+// defaultGCInterval is how often listenAndCompact wakes up to check
+// whether any value log segment has crossed ValueLogGCRatio.
+const defaultGCInterval = 10 * time.Minute
+
+// markStaleIfVlog looks up key's currently indexed entry and, if it points
+// into the value log, tells the value log those bytes are now stale,
+// since the write or delete about to land supersedes it. A value chained
+// across several segments has every one of its chunks marked stale, not
+// just the head's, so GC can eventually reclaim all of them rather than
+// leaking the tail chunks forever.
+func (cf *ColumnFamily) markStaleIfVlog(key []byte) {
+	prev, err := cf.indexer.Get(key)
+	if err != nil || prev == nil || prev.Size == 0 {
+		return
+	}
+	locs, err := cf.vlog.ChainLocations(prev.Fid, prev.Offset)
+	if err != nil {
+		// Best-effort: at least account for the head chunk.
+		cf.vlog.MarkStale(prev.Fid, prev.Size)
+		return
+	}
+	for _, loc := range locs {
+		cf.vlog.MarkStale(loc.Fid, loc.Size)
+	}
+}
+
+// listenAndCompact is the background value log GC loop, started alongside
+// listenAndFlush. It wakes up periodically and reclaims any segment whose
+// stale ratio is at or above ValueLogGCRatio.
+func (cf *ColumnFamily) listenAndCompact(ctx context.Context) {
+	if cf.opts.ValueLogGCRatio <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(defaultGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Best-effort: a failed pass is retried on the next tick.
+			_ = cf.RunValueLogGC(ctx, cf.opts.ValueLogGCRatio)
+		}
+	}
+}
+
+// RunValueLogGC reclaims every value log segment whose stale ratio is at
+// or above ratio: every entry still referenced by the index is copied into
+// the active segment and the index is repointed at its new location, one
+// PutBatch call per rewritten entry (the updates of a pass are not grouped
+// into one transaction), after which the old segment is unlinked.
+// It can also be called directly for a manually triggered GC pass.
+func (cf *ColumnFamily) RunValueLogGC(ctx context.Context, ratio float64) error {
+	if atomic.LoadInt64(&cf.openSnapshots) > 0 {
+		// A snapshot pins a point-in-time indexer/vlog read transaction,
+		// but buildLiveChunks only ever sees the *current* index - a key
+		// a snapshot still reads may have since moved on, and reclaiming
+		// its now-unreferenced old location here would break that
+		// snapshot's reads out from under it. Deferring the whole pass is
+		// simpler than tracking which locations each snapshot depends on,
+		// and listenAndCompact already retries on its next tick.
+		return nil
+	}
+
+	fids := cf.vlog.StaleSegments(ratio)
+	if len(fids) == 0 {
+		return nil
+	}
+
+	// Built once per pass: which physical chunk locations are still live,
+	// and which key's chain each belongs to. A chain can span several
+	// segments, so a chunk's segment being reclaimed doesn't necessarily
+	// mean its chain's head is too - this is what lets reclaimSegment tell
+	// a live interior chunk from a stale one without a false "not
+	// referenced by the index" read.
+	live, err := cf.buildLiveChunks()
+	if err != nil {
+		return err
+	}
+	// Tracks chains already rewritten this pass, so a chain whose chunks
+	// are split across two segments being reclaimed in the same pass is
+	// only copied once.
+	rewritten := make(map[string]bool)
+
+	for _, fid := range fids {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		start := time.Now()
+		if err := cf.reclaimSegment(fid, live, rewritten); err != nil {
+			return err
+		}
+		metrics.ObserveGCLatency(cf.opts.CfName, time.Since(start).Seconds())
+		atomic.AddInt64(&cf.gcRuns, 1)
+	}
+	return nil
+}
+
+// buildLiveChunks walks the entire index and, for every key whose value
+// lives in the value log, follows its chain to record every physical
+// record it occupies, keyed by location and mapped back to the owning key.
+func (cf *ColumnFamily) buildLiveChunks() (map[vlogChunkKey][]byte, error) {
+	iter, err := cf.indexer.Iter()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	live := make(map[vlogChunkKey][]byte)
+	for k, v := iter.First(); k != nil; k, v = iter.Next() {
+		meta := index.DecodeMeta(v)
+		if meta.Size == 0 {
+			// Inline value; never lived in the value log.
+			continue
+		}
+		locs, err := cf.vlog.ChainLocations(meta.Fid, meta.Offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range locs {
+			live[vlogChunkKey{loc.Fid, loc.Offset}] = k
+		}
+	}
+	return live, nil
+}
+
+// vlogChunkKey identifies one physical value log record by its location.
+type vlogChunkKey struct {
+	fid    uint32
+	offset int64
+}
+
+// reclaimSegment rewrites every chain that still owns a live chunk in fid
+// - head or interior, wherever the rest of the chain's chunks happen to
+// live - and unlinks fid once nothing in it is needed any more.
+func (cf *ColumnFamily) reclaimSegment(fid uint32, live map[vlogChunkKey][]byte, rewritten map[string]bool) error {
+	err := cf.vlog.ScanSegment(fid, func(offset, size int64, key, value []byte) error {
+		headKey, ok := live[vlogChunkKey{fid, offset}]
+		if !ok {
+			// Superseded since this record was written; already
+			// accounted for by markStaleIfVlog.
+			return nil
+		}
+		if rewritten[string(headKey)] {
+			// This chain's chunks were already copied out while
+			// reclaiming another segment earlier in this pass.
+			return nil
+		}
+
+		meta, err := cf.indexer.Get(headKey)
+		if err != nil {
+			return err
+		}
+		if meta.Size == 0 {
+			// No longer vlog-backed (overwritten with an inline value,
+			// or deleted) since buildLiveChunks ran.
+			return nil
+		}
+
+		ve, err := cf.vlog.Read(meta.Fid, meta.Size, meta.Offset)
+		if err != nil {
+			return err
+		}
+		oldLocs, err := cf.vlog.ChainLocations(meta.Fid, meta.Offset)
+		if err != nil {
+			return err
+		}
+
+		newFid, newOffset, newSize, err := cf.vlog.WriteLarge(headKey, ve.Value)
+		if err != nil {
+			return err
+		}
+		rewritten[string(headKey)] = true
+
+		// listenAndFlush runs concurrently and can land a newer write for
+		// headKey between the Get above and this PutBatch. Re-read the
+		// indexer right before writing back and bail out if it no longer
+		// matches what was read: blindly trusting the Get from above would
+		// silently revert that newer write with our stale rewritten copy.
+		cur, err := cf.indexer.Get(headKey)
+		if err != nil {
+			return err
+		}
+		if cur.Fid != meta.Fid || cur.Offset != meta.Offset || cur.Seq != meta.Seq {
+			// Already superseded; the copy we just made is dead on
+			// arrival, so account for its bytes instead of indexing it.
+			cf.vlog.MarkStale(newFid, newSize)
+			return nil
+		}
+
+		newEntry := index.IndexerKvnode{
+			Key:  headKey,
+			Meta: &index.IndexerMeta{Fid: newFid, Offset: newOffset, Size: newSize, Seq: meta.Seq},
+		}
+		if _, err := cf.indexer.PutBatch([]index.IndexerKvnode{newEntry}); err != nil {
+			return err
+		}
+
+		// The old chain is now fully superseded; mark every one of its
+		// chunks stale, not just whichever one lives in fid, so segments
+		// holding the rest of it get reclaimed in a future pass too.
+		for _, loc := range oldLocs {
+			cf.vlog.MarkStale(loc.Fid, loc.Size)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return cf.vlog.RemoveSegment(fid)
+}
@@ -0,0 +1,310 @@
+package lotusdb
+
+import (
+	"bytes"
+	"container/heap"
+
+	"github.com/flower-corp/lotusdb/index"
+)
+
+// IterOptions configures a ColumnFamily iterator.
+type IterOptions struct {
+	LowerBound []byte // Inclusive. Nil means unbounded below.
+	UpperBound []byte // Exclusive. Nil means unbounded above.
+	Reverse    bool   // Iterate from high to low keys instead of low to high.
+	KeysOnly   bool   // Skip resolving values (including value log reads).
+}
+
+// sourceIter is the minimal cursor shape shared by a memtable iterator and
+// an index.IndexerIter, letting both feed the same merge heap.
+type sourceIter interface {
+	Seek(seek []byte) (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+	Close() error
+}
+
+type sourceKind int8
+
+const (
+	sourceMemtable sourceKind = iota
+	sourceIndex
+)
+
+// heapItem is one live cursor in the merge heap.
+type heapItem struct {
+	it       sourceIter
+	kind     sourceKind
+	priority int // Lower wins ties; the active memtable is 0, the index last.
+	key      []byte
+	value    []byte
+}
+
+// iterHeap k-way merges every live source by key; among sources holding
+// the same key, the lowest priority (most recently written) wins.
+type iterHeap struct {
+	items   []*heapItem
+	reverse bool
+}
+
+func (h *iterHeap) Len() int { return len(h.items) }
+
+func (h *iterHeap) Less(i, j int) bool {
+	cmp := bytes.Compare(h.items[i].key, h.items[j].key)
+	if h.reverse {
+		cmp = -cmp
+	}
+	if cmp != 0 {
+		return cmp < 0
+	}
+	return h.items[i].priority < h.items[j].priority
+}
+
+func (h *iterHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *iterHeap) Push(x interface{}) { h.items = append(h.items, x.(*heapItem)) }
+
+func (h *iterHeap) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
+
+// Iterator is a consistent, merged view over every active/immutable
+// memtable and the on-disk index, modelled after Pebble's mergingIter.
+// Tombstones are filtered out as the iterator advances, so a key deleted
+// in a newer source never surfaces even if an older, already-flushed
+// source still holds its prior value. Value log indirections are resolved
+// lazily, only when Value is called on the winning key.
+type Iterator struct {
+	cf      *ColumnFamily
+	opts    IterOptions
+	sources []*heapItem
+	h       *iterHeap
+	current *heapItem
+	key     []byte
+	valid   bool
+}
+
+// NewIterator opens a merged iterator over the column family's current
+// memtables and index. It starts out invalid; call Seek to position it.
+func (cf *ColumnFamily) NewIterator(opts IterOptions) (*Iterator, error) {
+	tables := cf.getMemtables()
+	sources := make([]*heapItem, 0, len(tables)+1)
+
+	for i, mem := range tables {
+		it, err := mem.NewIterator()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, &heapItem{it: it, kind: sourceMemtable, priority: i})
+	}
+
+	indexIter, err := cf.indexer.Iter()
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, &heapItem{it: indexIter, kind: sourceIndex, priority: len(tables)})
+
+	return &Iterator{
+		cf:      cf,
+		opts:    opts,
+		sources: sources,
+		h:       &iterHeap{reverse: opts.Reverse},
+	}, nil
+}
+
+func (it *Iterator) inBounds(key []byte) bool {
+	if it.opts.LowerBound != nil && bytes.Compare(key, it.opts.LowerBound) < 0 {
+		return false
+	}
+	if it.opts.UpperBound != nil && bytes.Compare(key, it.opts.UpperBound) >= 0 {
+		return false
+	}
+	return true
+}
+
+// Seek positions the iterator at the first key >= seek (or, when Reverse,
+// the last key <= seek), respecting LowerBound/UpperBound.
+func (it *Iterator) Seek(seek []byte) bool {
+	it.h.items = it.h.items[:0]
+
+	for _, s := range it.sources {
+		key, value := s.it.Seek(seek)
+		if it.opts.Reverse && (key == nil || !bytes.Equal(key, seek)) {
+			// Seek always lands on the first key >= seek (or past the
+			// end, when there is none); walk one back to honor a reverse
+			// iterator's "<=" semantics.
+			key, value = s.it.Prev()
+		}
+		s.key, s.value = key, value
+		if key != nil && it.inBounds(key) {
+			heap.Push(it.h, s)
+		}
+	}
+
+	it.settle()
+	it.skipTombstones(!it.opts.Reverse)
+	return it.valid
+}
+
+// settle re-derives the iterator's current key/validity from the heap.
+func (it *Iterator) settle() {
+	if it.h.Len() == 0 {
+		it.valid = false
+		it.key = nil
+		it.current = nil
+		return
+	}
+	it.current = it.h.items[0]
+	it.key = it.current.key
+	it.valid = true
+}
+
+// isTombstone reports whether item is a delete marker rather than a live
+// value. Only a memtable source can hold one: DeleteWithOptions writes no
+// stageValue-encoded payload, so a memtable entry with an empty value can
+// only be a tombstone, never a Put (every encodeInlineValue/
+// encodeVlogPointer payload is at least one byte). The index never holds
+// tombstones at all, since flushing a delete removes the key outright.
+func isTombstone(item *heapItem) bool {
+	return item.kind == sourceMemtable && len(item.value) == 0
+}
+
+// skipTombstones advances past the current key, and every further key in
+// forward's direction, as long as the winning source is a tombstone.
+func (it *Iterator) skipTombstones(forward bool) {
+	for it.valid && isTombstone(it.current) {
+		it.step(forward)
+	}
+}
+
+// Next advances the iterator by one key in its configured direction,
+// stepping every source tied on the current key past it so duplicates
+// across memtables and the index collapse to a single entry.
+func (it *Iterator) Next() bool {
+	return it.advance(!it.opts.Reverse)
+}
+
+// Prev steps the iterator one key against its configured direction.
+func (it *Iterator) Prev() bool {
+	return it.advance(it.opts.Reverse)
+}
+
+func (it *Iterator) advance(forward bool) bool {
+	if !it.valid {
+		return false
+	}
+	it.step(forward)
+	it.skipTombstones(forward)
+	return it.valid
+}
+
+// step moves every source tied on the current key past it by one entry in
+// forward's direction, then re-derives the iterator's current key.
+func (it *Iterator) step(forward bool) {
+	cur := it.key
+
+	var tied []*heapItem
+	for it.h.Len() > 0 && bytes.Equal(it.h.items[0].key, cur) {
+		tied = append(tied, heap.Pop(it.h).(*heapItem))
+	}
+
+	for _, s := range tied {
+		var key, value []byte
+		if forward {
+			key, value = s.it.Next()
+		} else {
+			key, value = s.it.Prev()
+		}
+		s.key, s.value = key, value
+		if key != nil && it.inBounds(key) {
+			heap.Push(it.h, s)
+		}
+	}
+
+	it.settle()
+}
+
+// Valid reports whether the iterator is positioned at an entry.
+func (it *Iterator) Valid() bool {
+	return it.valid
+}
+
+// Key returns the current entry's key. It is only valid to call while
+// Valid reports true.
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+// Value resolves the current entry's value, decoding the index's encoded
+// meta and following the value log when the value isn't stored inline.
+// Memtable entries are already resolved to their live value.
+func (it *Iterator) Value() []byte {
+	if !it.valid || it.current == nil || it.opts.KeysOnly {
+		return nil
+	}
+	if it.current.kind == sourceMemtable {
+		value, err := it.cf.resolveStoredValue(it.current.value)
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+
+	meta := index.DecodeMeta(it.current.value)
+	if len(meta.Value) != 0 {
+		return meta.Value
+	}
+	if meta.Size != 0 {
+		ve, err := it.cf.vlog.Read(meta.Fid, meta.Size, meta.Offset)
+		if err != nil || len(ve.Value) == 0 {
+			return nil
+		}
+		return ve.Value
+	}
+	return nil
+}
+
+// Close releases every underlying source cursor.
+func (it *Iterator) Close() error {
+	var err error
+	for _, s := range it.sources {
+		if cerr := s.it.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Scan calls fn for every key with the given prefix in ascending order,
+// stopping as soon as fn returns false.
+func (cf *ColumnFamily) Scan(prefix []byte, fn func(key, value []byte) bool) error {
+	iter, err := cf.NewIterator(IterOptions{LowerBound: prefix, UpperBound: prefixUpperBound(prefix)})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	for ok := iter.Seek(prefix); ok; ok = iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return nil
+}
+
+// prefixUpperBound returns the smallest key that is not a match for
+// prefix, or nil (unbounded) if prefix is empty or all 0xff bytes.
+func prefixUpperBound(prefix []byte) []byte {
+	upper := append([]byte(nil), prefix...)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] != 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package lotusdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flower-corp/lotusdb/index"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSourceIter is a minimal in-memory sourceIter, letting the merge/
+// tombstone logic in Iterator be tested without a real memtable or indexer.
+type fakeSourceIter struct {
+	keys   [][]byte
+	values [][]byte
+	pos    int
+}
+
+func (f *fakeSourceIter) at() (key, value []byte) {
+	if f.pos < 0 || f.pos >= len(f.keys) {
+		return nil, nil
+	}
+	return f.keys[f.pos], f.values[f.pos]
+}
+
+func (f *fakeSourceIter) Seek(seek []byte) (key, value []byte) {
+	f.pos = len(f.keys)
+	for i, k := range f.keys {
+		if bytes.Compare(k, seek) >= 0 {
+			f.pos = i
+			break
+		}
+	}
+	return f.at()
+}
+
+func (f *fakeSourceIter) Next() (key, value []byte) {
+	f.pos++
+	return f.at()
+}
+
+func (f *fakeSourceIter) Prev() (key, value []byte) {
+	f.pos--
+	return f.at()
+}
+
+func (f *fakeSourceIter) Close() error { return nil }
+
+// TestIteratorSkipsTombstones checks that a key deleted in a newer
+// memtable source is never surfaced, even though an older, already-flushed
+// index source still holds its prior value.
+func TestIteratorSkipsTombstones(t *testing.T) {
+	mem := &fakeSourceIter{
+		keys:   [][]byte{[]byte("a")},
+		values: [][]byte{{}}, // tombstone: a delete leaves no stageValue payload.
+	}
+	idx := &fakeSourceIter{
+		keys:   [][]byte{[]byte("a"), []byte("b")},
+		values: [][]byte{encodeInlineValue([]byte("stale")), encodeInlineValue([]byte("fresh"))},
+	}
+
+	it := &Iterator{
+		sources: []*heapItem{
+			{it: mem, kind: sourceMemtable, priority: 0},
+			{it: idx, kind: sourceIndex, priority: 1},
+		},
+		h: &iterHeap{},
+	}
+
+	assert.True(t, it.Seek([]byte("a")))
+	assert.Equal(t, []byte("b"), it.Key())
+}
+
+// TestIteratorOverRealBPTree exercises the default BptreeBoltDB index
+// source through an actual boltIter instead of fakeSourceIter, guarding
+// against a regression where boltIter.Next/Prev built a fresh bbolt Cursor
+// on every call: since a cursor created that way carries no position
+// stack, Next/Prev always reported "no more keys" after the first key,
+// silently truncating every cf.Scan/NewIterator walk down to one entry.
+func TestIteratorOverRealBPTree(t *testing.T) {
+	opts := &index.BPTreeOptions{
+		ColumnFamilyName: "iter_over_real_bptree",
+		BucketName:       []byte("iter_over_real_bptree"),
+		DirPath:          t.TempDir(),
+	}
+	tree, err := index.NewIndexer(opts)
+	assert.Nil(t, err)
+
+	assert.Nil(t, tree.Put([]byte("a"), []byte("1")))
+	assert.Nil(t, tree.Put([]byte("b"), []byte("2")))
+	assert.Nil(t, tree.Put([]byte("c"), []byte("3")))
+
+	indexIter, err := tree.Iter()
+	assert.Nil(t, err)
+
+	it := &Iterator{
+		sources: []*heapItem{
+			{it: &fakeSourceIter{}, kind: sourceMemtable, priority: 0},
+			{it: indexIter, kind: sourceIndex, priority: 1},
+		},
+		h: &iterHeap{},
+	}
+
+	var got [][]byte
+	for ok := it.Seek(nil); ok; ok = it.Next() {
+		got = append(got, append([]byte(nil), it.Key()...))
+	}
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, got)
+}
@@ -0,0 +1,95 @@
+package lotusdb
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/flower-corp/lotusdb/index"
+	"github.com/flower-corp/lotusdb/memtable"
+)
+
+// ErrSnapshotClosed is returned by Snapshot methods once Close has run.
+var ErrSnapshotClosed = errors.New("lotusdb: snapshot is already closed")
+
+// Snapshot is a read-only, point-in-time view of a ColumnFamily. It pins
+// the memtables that were active when it was taken and a read transaction
+// against the indexer, so every Get sees the state as of that moment
+// regardless of writes applied afterwards.
+type Snapshot struct {
+	cf      *ColumnFamily
+	seq     uint64
+	tables  []*memtable.Memtable
+	indexTx index.ReadTx
+	vlog    *ColumnFamily // vlog reads are immutable by fid, so the live cf.vlog is reused.
+	closed  bool
+}
+
+// NewSnapshot pins the column family's current memtables and indexer state
+// behind the sequence number of the last applied write.
+func (cf *ColumnFamily) NewSnapshot() (*Snapshot, error) {
+	cf.mu.Lock()
+	seq := cf.seqNo
+	tables := cf.getMemtablesLocked()
+	cf.mu.Unlock()
+
+	indexTx, err := cf.indexer.NewReadTx()
+	if err != nil {
+		return nil, err
+	}
+
+	// Counted until Close; RunValueLogGC skips its whole pass while any
+	// snapshot is open; see ColumnFamily.openSnapshots.
+	atomic.AddInt64(&cf.openSnapshots, 1)
+
+	return &Snapshot{
+		cf:      cf,
+		seq:     seq,
+		tables:  tables,
+		indexTx: indexTx,
+		vlog:    cf,
+	}, nil
+}
+
+// Get reads key as it stood when the snapshot was taken, ignoring any
+// writes applied to the column family afterwards.
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	if s.closed {
+		return nil, ErrSnapshotClosed
+	}
+
+	for _, mem := range s.tables {
+		if raw, ok := mem.GetAsOf(key, s.seq); ok {
+			return s.cf.resolveStoredValue(raw)
+		}
+	}
+
+	indexMeta, err := s.indexTx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexMeta.Value) != 0 {
+		return indexMeta.Value, nil
+	}
+	if indexMeta.Size != 0 {
+		ve, err := s.vlog.vlog.Read(indexMeta.Fid, indexMeta.Size, indexMeta.Offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(ve.Value) != 0 {
+			return ve.Value, nil
+		}
+	}
+	return nil, nil
+}
+
+// Close releases the pinned indexer read transaction. It does not error on
+// the memtables, which are simply dropped, since they stay alive as long
+// as something still references them.
+func (s *Snapshot) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	atomic.AddInt64(&s.cf.openSnapshots, -1)
+	return s.indexTx.Close()
+}
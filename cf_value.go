@@ -0,0 +1,77 @@
+package lotusdb
+
+import "encoding/binary"
+
+// valueTag marks whether a memtable entry holds its value inline or only a
+// pointer into the value log, letting PutWithOptions divert large values
+// to vlog.WriteLarge while keeping Get/Iterator able to tell the two apart.
+type valueTag byte
+
+const (
+	valueInline valueTag = iota
+	valueVlogPointer
+)
+
+// encodeInlineValue frames a value that lives directly in the memtable.
+func encodeInlineValue(value []byte) []byte {
+	buf := make([]byte, 1+len(value))
+	buf[0] = byte(valueInline)
+	copy(buf[1:], value)
+	return buf
+}
+
+// encodeVlogPointer frames a pointer to a value already written to vlog.
+func encodeVlogPointer(fid uint32, offset, size int64) []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen32+2*binary.MaxVarintLen64)
+	buf[0] = byte(valueVlogPointer)
+	idx := 1
+	idx += binary.PutUvarint(buf[idx:], uint64(fid))
+	idx += binary.PutVarint(buf[idx:], offset)
+	idx += binary.PutVarint(buf[idx:], size)
+	return buf[:idx]
+}
+
+// decodeVlogPointer reverses encodeVlogPointer. raw must have its leading
+// valueVlogPointer tag byte already stripped.
+func decodeVlogPointer(raw []byte) (fid uint32, offset, size int64) {
+	idx := 0
+	fid64, n := binary.Uvarint(raw[idx:])
+	idx += n
+	offset, n = binary.Varint(raw[idx:])
+	idx += n
+	size, _ = binary.Varint(raw[idx:])
+	return uint32(fid64), offset, size
+}
+
+// resolveStoredValue reverses encodeInlineValue/encodeVlogPointer, reading
+// through to the value log for entries that were routed there.
+func (cf *ColumnFamily) resolveStoredValue(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if valueTag(raw[0]) == valueInline {
+		return raw[1:], nil
+	}
+
+	fid, offset, size := decodeVlogPointer(raw[1:])
+	ve, err := cf.vlog.Read(fid, size, offset)
+	if err != nil {
+		return nil, err
+	}
+	return ve.Value, nil
+}
+
+// stageValue encodes value for storage in the active memtable, writing it
+// to the value log first and storing only a pointer when it is at or above
+// LargeValueThreshold. key is carried into the value log record so the GC
+// scanner can tell whether it's still live.
+func (cf *ColumnFamily) stageValue(key, value []byte) ([]byte, error) {
+	if cf.opts.LargeValueThreshold > 0 && int64(len(value)) >= cf.opts.LargeValueThreshold {
+		fid, offset, size, err := cf.vlog.WriteLarge(key, value)
+		if err != nil {
+			return nil, err
+		}
+		return encodeVlogPointer(fid, offset, size), nil
+	}
+	return encodeInlineValue(value), nil
+}
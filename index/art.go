@@ -0,0 +1,331 @@
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	art "github.com/plar/go-adaptive-radix-tree"
+)
+
+const deltaLogSuffixName = ".dlog"
+
+// ARTOptions configures an in-memory adaptive radix tree indexer.
+type ARTOptions struct {
+	IndexType        IndexerType
+	ColumnFamilyName string
+	DirPath          string
+}
+
+func (ao *ARTOptions) SetType(typ IndexerType) {
+	ao.IndexType = typ
+}
+
+func (ao *ARTOptions) SetColumnFamilyName(cfName string) {
+	ao.ColumnFamilyName = cfName
+}
+
+func (ao *ARTOptions) SetDirPath(dirPath string) {
+	ao.DirPath = dirPath
+}
+
+func (ao *ARTOptions) GetType() IndexerType {
+	return ao.IndexType
+}
+
+func (ao *ARTOptions) GetColumnFamilyName() string {
+	return ao.ColumnFamilyName
+}
+
+func (ao *ARTOptions) GetDirPath() string {
+	return ao.DirPath
+}
+
+func checkARTOptions(opt *ARTOptions) error {
+	if opt.ColumnFamilyName == "" {
+		return ErrColumnFamilyNameNil
+	}
+	if opt.DirPath == "" {
+		return ErrDirPathNil
+	}
+	return nil
+}
+
+// deltaOp is the kind of mutation recorded in the delta log so the radix
+// tree can be rebuilt by replaying it on open.
+type deltaOp byte
+
+const (
+	deltaOpPut deltaOp = iota
+	deltaOpDelete
+)
+
+// ART is an in-memory indexer backed by an adaptive radix tree, guarded by
+// a single RWMutex the way BPTree is guarded by bbolt's own locking -
+// concurrent readers don't block each other, but writers still serialize.
+// Every mutation is first appended to a delta log on disk so the tree can
+// be rebuilt after a restart; unlike BptreeBoltDB it holds no mmap file
+// lock on that log.
+type ART struct {
+	tree    art.Tree
+	deltaFd *os.File
+	opts    *ARTOptions
+	mu      sync.RWMutex
+}
+
+// NewART opens (or creates) the adaptive radix tree indexer for a column
+// family, replaying its delta log to rebuild the in-memory tree.
+func NewART(opt *ARTOptions) (*ART, error) {
+	if err := checkARTOptions(opt); err != nil {
+		return nil, err
+	}
+
+	path := opt.DirPath + separator + opt.ColumnFamilyName + deltaLogSuffixName
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ART{
+		tree:    art.New(),
+		deltaFd: fd,
+		opts:    opt,
+	}
+	if err := a.loadDeltaLog(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// loadDeltaLog replays every record appended so far into the in-memory tree.
+func (a *ART) loadDeltaLog() error {
+	if _, err := a.deltaFd.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(a.deltaFd)
+
+	for {
+		op, key, value, err := readDeltaRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch op {
+		case deltaOpPut:
+			a.tree.Insert(key, value)
+		case deltaOpDelete:
+			a.tree.Delete(key)
+		}
+	}
+
+	_, err := a.deltaFd.Seek(0, io.SeekEnd)
+	return err
+}
+
+// appendDeltaRecord writes a single op/key/value record to the delta log,
+// framed as: op(1 byte) | keyLen(varint) | key | valLen(varint) | value.
+func (a *ART) appendDeltaRecord(op deltaOp, key, value []byte) error {
+	header := make([]byte, 1+2*binary.MaxVarintLen64)
+	header[0] = byte(op)
+	idx := 1
+	idx += binary.PutUvarint(header[idx:], uint64(len(key)))
+	idx += binary.PutUvarint(header[idx:], uint64(len(value)))
+
+	buf := make([]byte, 0, idx+len(key)+len(value))
+	buf = append(buf, header[:idx]...)
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+
+	_, err := a.deltaFd.Write(buf)
+	return err
+}
+
+func readDeltaRecord(r *bufio.Reader) (op deltaOp, key, value []byte, err error) {
+	opByte, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	valLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	key = make([]byte, keyLen)
+	if _, err = io.ReadFull(r, key); err != nil {
+		return 0, nil, nil, err
+	}
+	value = make([]byte, valLen)
+	if _, err = io.ReadFull(r, value); err != nil {
+		return 0, nil, nil, err
+	}
+	return deltaOp(opByte), key, value, nil
+}
+
+// Put writes a single key/value pair, logging it before updating the tree.
+func (a *ART) Put(key, value []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	encoded := encodeMeta(&IndexerMeta{Value: value})
+	if err := a.appendDeltaRecord(deltaOpPut, key, encoded); err != nil {
+		return err
+	}
+	a.tree.Insert(key, encoded)
+	return nil
+}
+
+// PutBatch writes every entry in kv, one delta record and tree insert at a
+// time; offset mirrors BPTree.PutBatch so a failed batch can be retried
+// starting from the returned offset.
+func (a *ART) PutBatch(kv []IndexerKvnode) (offset int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for offset = 0; offset < len(kv); offset++ {
+		encoded := encodeMeta(kv[offset].Meta)
+		if err = a.appendDeltaRecord(deltaOpPut, kv[offset].Key, encoded); err != nil {
+			return offset, err
+		}
+		a.tree.Insert(kv[offset].Key, encoded)
+	}
+	return len(kv) - 1, nil
+}
+
+// Get looks up key in the in-memory tree.
+func (a *ART) Get(key []byte) (*IndexerMeta, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	raw, found := a.tree.Search(key)
+	if !found {
+		return &IndexerMeta{Key: key}, nil
+	}
+
+	meta := decodeMeta(raw.([]byte))
+	meta.Key = key
+	return meta, nil
+}
+
+// Delete removes key, logging the deletion before updating the tree.
+func (a *ART) Delete(key []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.appendDeltaRecord(deltaOpDelete, key, nil); err != nil {
+		return err
+	}
+	a.tree.Delete(key)
+	return nil
+}
+
+// Close flushes and closes the delta log file.
+func (a *ART) Close() error {
+	if err := a.deltaFd.Sync(); err != nil {
+		return err
+	}
+	return a.deltaFd.Close()
+}
+
+// artReadTx is ART's ReadTx: since the tree mutates in place and has no
+// native transaction concept, NewReadTx takes a shallow copy of every
+// key/value pair so Gets against it stay stable even as the live tree
+// keeps changing.
+type artReadTx struct {
+	snapshot map[string][]byte
+}
+
+// NewReadTx snapshots the tree's current contents for point-in-time reads.
+func (a *ART) NewReadTx() (ReadTx, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	snap := make(map[string][]byte)
+	a.tree.ForEach(func(node art.Node) bool {
+		snap[string(node.Key())] = node.Value().([]byte)
+		return true
+	})
+	return &artReadTx{snapshot: snap}, nil
+}
+
+func (t *artReadTx) Get(key []byte) (*IndexerMeta, error) {
+	raw, ok := t.snapshot[string(key)]
+	if !ok {
+		return &IndexerMeta{Key: key}, nil
+	}
+	meta := decodeMeta(raw)
+	meta.Key = key
+	return meta, nil
+}
+
+func (t *artReadTx) Close() error {
+	return nil
+}
+
+// artIter walks a snapshot of the tree's keys in sorted order; the radix
+// tree is sorted natively, so no extra sort step is needed.
+type artIter struct {
+	keys [][]byte
+	vals [][]byte
+	pos  int
+}
+
+// Iter returns an iterator over the tree's current contents. Adaptive
+// radix trees are ordered natively, so an in-order walk is all that's
+// needed to get a BPTree-compatible cursor.
+func (a *ART) Iter() (IndexerIter, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	it := &artIter{pos: -1}
+	a.tree.ForEach(func(node art.Node) bool {
+		it.keys = append(it.keys, node.Key())
+		it.vals = append(it.vals, node.Value().([]byte))
+		return true
+	})
+	return it, nil
+}
+
+func (i *artIter) at(pos int) (key, value []byte) {
+	if pos < 0 || pos >= len(i.keys) {
+		return nil, nil
+	}
+	i.pos = pos
+	return i.keys[pos], i.vals[pos]
+}
+
+func (i *artIter) First() (key, value []byte) {
+	return i.at(0)
+}
+
+func (i *artIter) Last() (key, value []byte) {
+	return i.at(len(i.keys) - 1)
+}
+
+func (i *artIter) Next() (key, value []byte) {
+	return i.at(i.pos + 1)
+}
+
+func (i *artIter) Prev() (key, value []byte) {
+	return i.at(i.pos - 1)
+}
+
+func (i *artIter) Seek(seek []byte) (key, value []byte) {
+	for idx, k := range i.keys {
+		if string(k) >= string(seek) {
+			return i.at(idx)
+		}
+	}
+	i.pos = len(i.keys)
+	return nil, nil
+}
+
+func (i *artIter) Close() error { return nil }
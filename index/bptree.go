@@ -1,7 +1,9 @@
 package index
 
 import (
+	"encoding/binary"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.etcd.io/bbolt"
@@ -23,6 +25,11 @@ type BPTree struct {
 
 	// todo
 	metedatadb *bbolt.DB
+
+	// txCount counts every bbolt transaction run through Put, Get, Delete
+	// or PutBatch, read-only ones included, surfaced via TxCount for
+	// metrics reporting.
+	txCount int64
 }
 
 type BPTreeManager struct {
@@ -179,12 +186,16 @@ func (b *BPTree) Put(k, v []byte) (err error) {
 
 	bucket := tx.Bucket(b.opts.BucketName)
 
-	err = bucket.Put(k, v)
+	err = bucket.Put(k, encodeMeta(&IndexerMeta{Value: v}))
 	if err != nil {
 		return
 	}
 
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	atomic.AddInt64(&b.txCount, 1)
+	return nil
 }
 
 // PutBatch is used for batch writing scenarios.
@@ -215,7 +226,7 @@ func (b *BPTree) PutBatch(kv []IndexerKvnode) (offset int, err error) {
 			if itemIdx > len(kv) {
 				break itemLoop
 			}
-			if err := bucket.Put(kv[itemIdx].Key, kv[itemIdx].Value); err != nil {
+			if err := bucket.Put(kv[itemIdx].Key, encodeMeta(kv[itemIdx].Meta)); err != nil {
 				tx.Rollback()
 				return offset, err
 			}
@@ -224,6 +235,7 @@ func (b *BPTree) PutBatch(kv []IndexerKvnode) (offset int, err error) {
 		if err := tx.Commit(); err != nil {
 			return offset, err
 		}
+		atomic.AddInt64(&b.txCount, 1)
 	}
 	return len(kv) - 1, nil
 }
@@ -234,20 +246,119 @@ func (b *BPTree) Delete(key []byte) error {
 		return err
 	}
 	defer tx.Commit()
+	defer atomic.AddInt64(&b.txCount, 1)
 
 	return tx.Bucket(b.opts.BucketName).Delete(key)
 }
 
 // Get The put method starts a transaction.
-// This method reads the value from the bucket with key,
-func (b *BPTree) Get(key []byte) (value []byte, err error) {
+// This method reads the indexer meta from the bucket with key.
+func (b *BPTree) Get(key []byte) (*IndexerMeta, error) {
 	tx, err := b.db.Begin(false)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
+	defer atomic.AddInt64(&b.txCount, 1)
+
+	raw := tx.Bucket(b.opts.BucketName).Get(key)
+	if raw == nil {
+		return &IndexerMeta{Key: key}, nil
+	}
+
+	meta := decodeMeta(raw)
+	meta.Key = key
+	return meta, nil
+}
+
+// metaTag distinguishes the two shapes an encoded IndexerMeta can take:
+// a value stored directly, or a pointer into the value log.
+const (
+	metaTagValue byte = iota
+	metaTagVlog
+)
+
+// encodeMeta flattens an IndexerMeta into the byte slice stored as a bolt
+// value, so BptreeBoltDB and ArtRadixTree persist the exact same shape.
+func encodeMeta(meta *IndexerMeta) []byte {
+	if len(meta.Value) > 0 || meta.Size == 0 {
+		buf := make([]byte, 1+binary.MaxVarintLen64+len(meta.Value))
+		buf[0] = metaTagValue
+		idx := 1
+		idx += binary.PutUvarint(buf[idx:], meta.Seq)
+		idx += copy(buf[idx:], meta.Value)
+		return buf[:idx]
+	}
+
+	buf := make([]byte, 1+binary.MaxVarintLen32+3*binary.MaxVarintLen64)
+	buf[0] = metaTagVlog
+	idx := 1
+	idx += binary.PutUvarint(buf[idx:], uint64(meta.Fid))
+	idx += binary.PutVarint(buf[idx:], meta.Offset)
+	idx += binary.PutVarint(buf[idx:], meta.Size)
+	idx += binary.PutUvarint(buf[idx:], meta.Seq)
+	return buf[:idx]
+}
+
+// decodeMeta reverses encodeMeta.
+func decodeMeta(buf []byte) *IndexerMeta {
+	if len(buf) == 0 {
+		return &IndexerMeta{}
+	}
+	if buf[0] == metaTagValue {
+		idx := 1
+		seq, n := binary.Uvarint(buf[idx:])
+		idx += n
+		return &IndexerMeta{Value: buf[idx:], Seq: seq}
+	}
+
+	idx := 1
+	fid, n := binary.Uvarint(buf[idx:])
+	idx += n
+	offset, n := binary.Varint(buf[idx:])
+	idx += n
+	size, n := binary.Varint(buf[idx:])
+	idx += n
+	seq, _ := binary.Uvarint(buf[idx:])
+	return &IndexerMeta{Fid: uint32(fid), Offset: offset, Size: size, Seq: seq}
+}
+
+// boltReadTx is a BPTree.ReadTx pinned to a single bbolt read transaction,
+// so every Get it serves sees the same on-disk snapshot.
+type boltReadTx struct {
+	bucketName []byte
+	tx         *bbolt.Tx
+}
+
+// NewReadTx opens a bbolt read transaction and keeps it open until Close,
+// giving callers (ColumnFamily snapshots) a consistent point-in-time view.
+func (b *BPTree) NewReadTx() (ReadTx, error) {
+	tx, err := b.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &boltReadTx{bucketName: b.opts.BucketName, tx: tx}, nil
+}
+
+func (t *boltReadTx) Get(key []byte) (*IndexerMeta, error) {
+	raw := t.tx.Bucket(t.bucketName).Get(key)
+	if raw == nil {
+		return &IndexerMeta{Key: key}, nil
+	}
+	meta := decodeMeta(raw)
+	meta.Key = key
+	return meta, nil
+}
+
+func (t *boltReadTx) Close() error {
+	return t.tx.Rollback()
+}
 
-	return tx.Bucket(b.opts.BucketName).Get(key), nil
+// TxCount reports the number of bbolt transactions, read-only ones
+// included, run so far by this indexer, for operators tracking index
+// write/read amplification.
+func (b *BPTree) TxCount() int64 {
+	return atomic.LoadInt64(&b.txCount)
 }
 
 func (b *BPTree) Close() (err error) {
@@ -260,9 +371,15 @@ func (b *BPTree) Close() (err error) {
 	return nil
 }
 
+// boltIter walks a BPTree's bucket through a single bbolt Cursor held for
+// the iterator's lifetime. A bbolt Cursor carries its own position stack,
+// so Next/Prev must run on the exact same Cursor a prior First/Seek used -
+// calling Bucket.Cursor() fresh on every call returns a cursor with no
+// stack at all, making Next/Prev always report "no more keys".
 type boltIter struct {
 	b        *BPTree
 	dbBucket *bbolt.Bucket
+	cursor   *bbolt.Cursor
 	tx       *bbolt.Tx
 }
 
@@ -280,28 +397,29 @@ func (b *BPTree) Iter() (IndexerIter, error) {
 	return &boltIter{
 		b:        b,
 		dbBucket: bucket,
+		cursor:   bucket.Cursor(),
 		tx:       tx,
 	}, nil
 }
 
 func (b *boltIter) First() (key, value []byte) {
-	return b.dbBucket.Cursor().First()
+	return b.cursor.First()
 }
 
 func (b *boltIter) Last() (key, value []byte) {
-	return b.dbBucket.Cursor().Last()
+	return b.cursor.Last()
 }
 
 func (b *boltIter) Seek(seek []byte) (key, value []byte) {
-	return b.dbBucket.Cursor().Seek(seek)
+	return b.cursor.Seek(seek)
 }
 
 func (b *boltIter) Next() (key, value []byte) {
-	return b.dbBucket.Cursor().Next()
+	return b.cursor.Next()
 }
 
 func (b *boltIter) Prev() (key, value []byte) {
-	return b.dbBucket.Cursor().Prev()
+	return b.cursor.Prev()
 }
 
 func (b *boltIter) Close() (err error) {
@@ -0,0 +1,42 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBoltIterNextPrev guards against boltIter.Next/Prev building a fresh
+// bbolt Cursor on every call: a cursor created fresh has no position stack,
+// so Next/Prev off of it always report "no more keys" regardless of any
+// prior First/Seek, silently truncating every full-index walk (the
+// GC/iterator bug this test was added for) to just the first key.
+func TestBoltIterNextPrev(t *testing.T) {
+	opts := &BPTreeOptions{
+		ColumnFamilyName: "bolt_iter_next_prev",
+		BucketName:       []byte("bolt_iter_next_prev"),
+		DirPath:          t.TempDir(),
+	}
+	tree, err := BptreeBolt(opts)
+	assert.Nil(t, err)
+
+	assert.Nil(t, tree.Put([]byte("a"), []byte("1")))
+	assert.Nil(t, tree.Put([]byte("b"), []byte("2")))
+	assert.Nil(t, tree.Put([]byte("c"), []byte("3")))
+
+	iter, err := tree.Iter()
+	assert.Nil(t, err)
+	defer iter.Close()
+
+	k, _ := iter.First()
+	assert.Equal(t, []byte("a"), k)
+
+	k, _ = iter.Next()
+	assert.Equal(t, []byte("b"), k)
+
+	k, _ = iter.Next()
+	assert.Equal(t, []byte("c"), k)
+
+	k, _ = iter.Next()
+	assert.Nil(t, k)
+}
@@ -0,0 +1,120 @@
+package index
+
+import "errors"
+
+// IndexerType represents the different indexing structures that are
+// built into lotusdb. A ColumnFamily picks one of these at open time.
+type IndexerType int8
+
+const (
+	// BptreeBoltDB indexes data with BoltDB, a persistent on-disk B+tree.
+	// It is mmap-backed and holds a lock on its column family's own index
+	// file, so only one process may open a given column family's index at
+	// a time.
+	BptreeBoltDB IndexerType = iota
+
+	// ArtRadixTree indexes data with an in-memory adaptive radix tree.
+	// Writes are appended to a delta log first so the tree can be rebuilt
+	// on restart; the delta log is opened without a file lock (writers
+	// still serialize behind a mutex, same as BptreeBoltDB).
+	ArtRadixTree
+)
+
+var (
+	// ErrColumnFamilyNameNil column family name is nil.
+	ErrColumnFamilyNameNil = errors.New("index: column family name is nil")
+
+	// ErrDirPathNil dir path is nil.
+	ErrDirPathNil = errors.New("index: dir path is nil")
+
+	// ErrBucketNameNil bucket name is nil.
+	ErrBucketNameNil = errors.New("index: bucket name is nil")
+
+	// ErrBucketNotInit bucket is not initialized.
+	ErrBucketNotInit = errors.New("index: bucket not initialized")
+
+	// ErrIndexerTypeNotSupported the given indexer type/options combination
+	// is not supported by NewIndexer.
+	ErrIndexerTypeNotSupported = errors.New("index: indexer type is not supported")
+)
+
+// IndexerMeta is what an Indexer stores and returns for a key. Small values
+// are held directly in Value; larger ones are written to the value log and
+// only their location(Fid/Offset/Size) is kept here.
+type IndexerMeta struct {
+	Key    []byte
+	Value  []byte
+	Fid    uint32
+	Offset int64
+	Size   int64
+	Seq    uint64 // Sequence number of the write that produced this entry.
+}
+
+// IndexerKvnode is a single entry passed to PutBatch, pairing a key with
+// either its value or its value log location.
+type IndexerKvnode struct {
+	Key  []byte
+	Meta *IndexerMeta
+}
+
+// IndexerIter is the iteration interface implemented by every indexer,
+// modelled after bbolt's Cursor.
+type IndexerIter interface {
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	Seek(seek []byte) (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+	Close() error
+}
+
+// Indexer is the interface that BptreeBoltDB and ArtRadixTree both satisfy,
+// Get/PutBatch semantics must be identical across implementations.
+type Indexer interface {
+	Put(key, value []byte) error
+	PutBatch(kv []IndexerKvnode) (offset int, err error)
+	Get(key []byte) (*IndexerMeta, error)
+	Delete(key []byte) error
+	Iter() (IndexerIter, error)
+	NewReadTx() (ReadTx, error)
+	Close() error
+}
+
+// ReadTx is a pinned, point-in-time read-only view into an Indexer. BPTree
+// backs it with a real bbolt read transaction; ART, which has no native
+// transaction concept, backs it with a shallow copy of its current keys.
+type ReadTx interface {
+	Get(key []byte) (*IndexerMeta, error)
+	Close() error
+}
+
+// Options is implemented by BPTreeOptions and ARTOptions, and is the
+// argument type accepted by NewIndexer.
+type Options interface {
+	SetType(typ IndexerType)
+	SetColumnFamilyName(cfName string)
+	SetDirPath(dirPath string)
+	GetType() IndexerType
+	GetColumnFamilyName() string
+	GetDirPath() string
+}
+
+// DecodeMeta decodes the raw bytes an Indexer's Iter cursor hands back into
+// an IndexerMeta, for callers (such as ColumnFamily's merging iterator)
+// that walk an Iter directly instead of going through Get.
+func DecodeMeta(raw []byte) *IndexerMeta {
+	return decodeMeta(raw)
+}
+
+// NewIndexer opens the indexer requested by opts, dispatching on its
+// concrete type.
+func NewIndexer(opts Options) (Indexer, error) {
+	switch opt := opts.(type) {
+	case *BPTreeOptions:
+		return BptreeBolt(opt)
+	case *ARTOptions:
+		return NewART(opt)
+	default:
+		return nil, ErrIndexerTypeNotSupported
+	}
+}
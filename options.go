@@ -0,0 +1,70 @@
+package lotusdb
+
+import "github.com/flower-corp/lotusdb/index"
+
+// MemtableType selects which skiplist-based representation backs a column
+// family's memtables.
+type MemtableType int8
+
+const (
+	// SkipList backs the memtable with a plain skip list.
+	SkipList MemtableType = iota
+
+	// HashSkipList backs the memtable with a hashmap of per-bucket skip
+	// lists, trading memory for faster point lookups.
+	HashSkipList
+)
+
+// ColumnFamilyOptions holds the parameters OpenColumnFamily needs to open or
+// create a column family: where it lives on disk, how its memtables and
+// value log are sized, and which index and value log backends to use.
+type ColumnFamilyOptions struct {
+	// CfName is the column family's name. Required.
+	CfName string
+
+	// DirPath is the column family's root directory. Defaults to the
+	// parent LotusDB's DBPath when empty.
+	DirPath string
+
+	// MemtableNums is the number of immutable memtables, plus the active
+	// one, kept in memory before a write blocks waiting for a flush.
+	MemtableNums int
+
+	// MemtableSize is the size, in bytes, a memtable grows to before it
+	// is rotated out to be flushed.
+	MemtableSize int64
+
+	// MemtableType selects the memtable's skiplist representation.
+	MemtableType MemtableType
+
+	// WalDir is the directory memtable WAL segments are written to.
+	WalDir string
+
+	// WalMMap opens WAL segments with mmap'd IO instead of plain file IO.
+	WalMMap bool
+
+	// ValueLogDir is the directory value log segments are written to.
+	ValueLogDir string
+
+	// ValueLogBlockSize is the size, in bytes, a value log segment grows
+	// to before a new one is created.
+	ValueLogBlockSize int64
+
+	// ValueLogMmap opens value log segments with mmap'd IO instead of
+	// plain file IO.
+	ValueLogMmap bool
+
+	// ValueLogGCRatio is the stale-byte ratio a value log segment must
+	// reach before listenAndCompact reclaims it. Zero disables background
+	// GC.
+	ValueLogGCRatio float64
+
+	// LargeValueThreshold is the size, in bytes, at or above which a
+	// value is routed to the value log instead of being stored inline in
+	// the memtable.
+	LargeValueThreshold int64
+
+	// IndexerType selects the on-disk index backend: a BoltDB B+tree by
+	// default, or an in-memory adaptive radix tree.
+	IndexerType index.IndexerType
+}
@@ -0,0 +1,200 @@
+package vlog
+
+import (
+	"os"
+)
+
+// WriteLarge writes value as a FormatV2 chunked record: it is split into
+// blockSize-sized pieces, each written as its own record with a chained
+// header pointing at the next one, so a single logical value can span
+// multiple physical segment files. It returns the location of the first
+// chunk, which is what the index stores and Read follows.
+func (vlog *ValueLog) WriteLarge(key, value []byte) (fid uint32, offset int64, size int64, err error) {
+	vlog.mu.Lock()
+	defer vlog.mu.Unlock()
+
+	if vlog.closed {
+		return 0, 0, 0, ErrValueLogClosed
+	}
+	if vlog.blockSize <= 0 || int64(len(value)) <= vlog.blockSize {
+		seg, err := vlog.activeSegment()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		fid, offset, size, err = seg.writeRecord(recordFinal, 0, 0, key, value)
+		if err == nil {
+			vlog.trackWriteLocked(fid, size)
+		}
+		return fid, offset, size, err
+	}
+
+	// Write chunks back to front so each one's header can point at the
+	// chunk already written after it; the very last chunk is recordFinal.
+	// Only the head chunk (written last, at start == 0) carries key, since
+	// that's the only offset any index entry or GC lookup refers to.
+	var (
+		nextFid    uint32
+		nextOffset int64
+		tag        = recordFinal
+	)
+	for start := len(value); start > 0; {
+		end := start
+		start -= int(vlog.blockSize)
+		if start < 0 {
+			start = 0
+		}
+		chunk := value[start:end]
+		var chunkKey []byte
+		if start == 0 {
+			chunkKey = key
+		}
+
+		seg, err := vlog.activeSegment()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		chunkFid, chunkOffset, chunkSize, err := seg.writeRecord(tag, nextFid, nextOffset, chunkKey, chunk)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		vlog.trackWriteLocked(chunkFid, chunkSize)
+		fid, offset, size = chunkFid, chunkOffset, chunkSize
+		nextFid, nextOffset, tag = chunkFid, chunkOffset, recordChained
+	}
+	return fid, offset, size, nil
+}
+
+// readChain follows a (possibly single-chunk) record starting at
+// fid/offset and concatenates every chunk into the full logical value.
+func (vlog *ValueLog) readChain(fid uint32, offset int64) ([]byte, error) {
+	var out []byte
+	for {
+		seg, ok := vlog.segments[fid]
+		if !ok {
+			return nil, ErrSegmentNotFound
+		}
+
+		_, value, tag, nextFid, nextOffset, _, err := seg.readRecord(offset)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, value...)
+		if tag == recordFinal {
+			return out, nil
+		}
+		fid, offset = nextFid, nextOffset
+	}
+}
+
+// stagingSuffix names the temporary file an in-progress batch spills its
+// chunked writes to before it is committed into the active segment set.
+const stagingSuffix = ".vlog.staging"
+
+// Staging is an in-progress batch's private, on-disk scratch space:
+// writes are appended to a temporary file (bounding batch size by disk
+// rather than RAM) and only become visible to readers once Commit links
+// the file in as a new segment.
+type Staging struct {
+	vlog *ValueLog
+	fd   *os.File
+	path string
+	fid  uint32
+	seg  *segment
+}
+
+// NewStaging opens a fresh staging file for an in-progress batch.
+func (vlog *ValueLog) NewStaging() (*Staging, error) {
+	vlog.mu.Lock()
+	fid := vlog.activeFid + 1
+	for _, exists := vlog.segments[fid]; exists; _, exists = vlog.segments[fid] {
+		fid++
+	}
+	vlog.mu.Unlock()
+
+	path := segmentPath(vlog.dirPath, fid) + stagingSuffix
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fd.Write([]byte{byte(FormatV2)}); err != nil {
+		return nil, err
+	}
+
+	return &Staging{
+		vlog: vlog,
+		fd:   fd,
+		path: path,
+		fid:  fid,
+		seg:  &segment{fid: fid, fd: fd, format: FormatV2, writeAt: fileHeaderSize},
+	}, nil
+}
+
+// Write appends value to the staging file, chunking it into blockSize-sized
+// records exactly as WriteLarge would, except every chunk lands in this
+// Staging's own file rather than rotating across the live segment set -
+// the whole point is to keep the in-progress batch off to the side until
+// Commit links it in.
+func (st *Staging) Write(key, value []byte) (fid uint32, offset int64, size int64, err error) {
+	blockSize := st.vlog.blockSize
+	if blockSize <= 0 || int64(len(value)) <= blockSize {
+		return st.seg.writeRecord(recordFinal, 0, 0, key, value)
+	}
+
+	// Mirrors WriteLarge's back-to-front chunking loop, but every chunk is
+	// written to st.seg instead of vlog.activeSegment().
+	var (
+		nextFid    uint32
+		nextOffset int64
+		tag        = recordFinal
+	)
+	for start := len(value); start > 0; {
+		end := start
+		start -= int(blockSize)
+		if start < 0 {
+			start = 0
+		}
+		chunk := value[start:end]
+		var chunkKey []byte
+		if start == 0 {
+			chunkKey = key
+		}
+
+		chunkFid, chunkOffset, chunkSize, werr := st.seg.writeRecord(tag, nextFid, nextOffset, chunkKey, chunk)
+		if werr != nil {
+			return 0, 0, 0, werr
+		}
+		fid, offset, size = chunkFid, chunkOffset, chunkSize
+		nextFid, nextOffset, tag = chunkFid, chunkOffset, recordChained
+	}
+	return fid, offset, size, nil
+}
+
+// Commit fsyncs and atomically renames the staging file into the active
+// segment set, making every record written to it visible to readers under
+// its staged fid.
+func (st *Staging) Commit() error {
+	if err := st.fd.Sync(); err != nil {
+		return err
+	}
+
+	finalPath := segmentPath(st.vlog.dirPath, st.fid)
+	if err := os.Rename(st.path, finalPath); err != nil {
+		return err
+	}
+
+	st.vlog.mu.Lock()
+	defer st.vlog.mu.Unlock()
+	st.vlog.segments[st.fid] = st.seg
+	st.vlog.trackWriteLocked(st.fid, st.seg.writeAt-fileHeaderSize)
+	if st.fid > st.vlog.activeFid {
+		st.vlog.activeFid = st.fid
+	}
+	return nil
+}
+
+// Discard removes the staging file without making its writes visible,
+// used when the batch they belong to is abandoned instead of committed.
+func (st *Staging) Discard() error {
+	_ = st.fd.Close()
+	return os.Remove(st.path)
+}
@@ -0,0 +1,151 @@
+package vlog
+
+import "os"
+
+// FidStat holds the live/stale byte counters for one segment file, used to
+// decide which segments are worth reclaiming.
+type FidStat struct {
+	LiveBytes  int64
+	StaleBytes int64
+}
+
+// trackWriteLocked records that size bytes of new, live data were written
+// to fid. Callers must already hold vlog.mu.
+func (vlog *ValueLog) trackWriteLocked(fid uint32, size int64) {
+	st, ok := vlog.stats[fid]
+	if !ok {
+		st = &FidStat{}
+		vlog.stats[fid] = st
+	}
+	st.LiveBytes += size
+}
+
+// MarkStale records that a size-byte record previously written to fid is
+// no longer referenced by the index, having been overwritten or deleted.
+func (vlog *ValueLog) MarkStale(fid uint32, size int64) {
+	vlog.mu.Lock()
+	defer vlog.mu.Unlock()
+
+	st, ok := vlog.stats[fid]
+	if !ok {
+		st = &FidStat{}
+		vlog.stats[fid] = st
+	}
+	st.StaleBytes += size
+	if st.LiveBytes >= size {
+		st.LiveBytes -= size
+	}
+}
+
+// Stats returns a snapshot of the live/stale byte counters for every
+// segment the value log has ever written to.
+func (vlog *ValueLog) Stats() map[uint32]FidStat {
+	vlog.mu.RLock()
+	defer vlog.mu.RUnlock()
+
+	out := make(map[uint32]FidStat, len(vlog.stats))
+	for fid, st := range vlog.stats {
+		out[fid] = *st
+	}
+	return out
+}
+
+// StaleSegments returns the fids, excluding the active one, whose stale
+// ratio is at or above ratio.
+func (vlog *ValueLog) StaleSegments(ratio float64) []uint32 {
+	vlog.mu.RLock()
+	defer vlog.mu.RUnlock()
+
+	var fids []uint32
+	for fid, st := range vlog.stats {
+		if fid == vlog.activeFid {
+			continue
+		}
+		total := st.LiveBytes + st.StaleBytes
+		if total > 0 && float64(st.StaleBytes)/float64(total) >= ratio {
+			fids = append(fids, fid)
+		}
+	}
+	return fids
+}
+
+// ChunkLocation identifies one physical record making up a chained value,
+// as returned by ChainLocations.
+type ChunkLocation struct {
+	Fid    uint32
+	Offset int64
+	Size   int64
+}
+
+// ChainLocations follows the (possibly single-chunk) chain starting at
+// fid/offset, the way readChain does, but returns every physical record
+// location along the way instead of the reassembled value. GC uses this to
+// tell a live interior chunk from a stale one, and to mark every chunk of
+// a superseded chain stale, wherever each one happens to live.
+func (vlog *ValueLog) ChainLocations(fid uint32, offset int64) ([]ChunkLocation, error) {
+	vlog.mu.RLock()
+	defer vlog.mu.RUnlock()
+
+	var out []ChunkLocation
+	for {
+		seg, ok := vlog.segments[fid]
+		if !ok {
+			return nil, ErrSegmentNotFound
+		}
+
+		_, _, tag, nextFid, nextOffset, recordLen, err := seg.readRecord(offset)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ChunkLocation{Fid: fid, Offset: offset, Size: recordLen})
+		if tag == recordFinal {
+			return out, nil
+		}
+		fid, offset = nextFid, nextOffset
+	}
+}
+
+// ScanSegment calls fn with the key/value of every record stored in fid,
+// in file order, so a caller can decide which of them are still live.
+// Interior chunks of a value chained across segments carry no key; fn is
+// called with an empty key for those, so a caller that needs to know which
+// chain an interior chunk belongs to has to match it by fid/offset (see
+// ChainLocations).
+func (vlog *ValueLog) ScanSegment(fid uint32, fn func(offset, size int64, key, value []byte) error) error {
+	vlog.mu.RLock()
+	seg, ok := vlog.segments[fid]
+	vlog.mu.RUnlock()
+	if !ok {
+		return ErrSegmentNotFound
+	}
+
+	for offset := int64(fileHeaderSize); offset < seg.writeAt; {
+		key, value, _, _, _, recordLen, err := seg.readRecord(offset)
+		if err != nil {
+			return err
+		}
+		if err := fn(offset, recordLen, key, value); err != nil {
+			return err
+		}
+		offset += recordLen
+	}
+	return nil
+}
+
+// RemoveSegment closes and deletes fid's file. Callers must only do this
+// after every still-live record in it has been copied elsewhere.
+func (vlog *ValueLog) RemoveSegment(fid uint32) error {
+	vlog.mu.Lock()
+	defer vlog.mu.Unlock()
+
+	seg, ok := vlog.segments[fid]
+	if !ok {
+		return ErrSegmentNotFound
+	}
+	if err := seg.fd.Close(); err != nil {
+		return err
+	}
+	delete(vlog.segments, fid)
+	delete(vlog.stats, fid)
+	return os.Remove(segmentPath(vlog.dirPath, fid))
+}
@@ -0,0 +1,32 @@
+package vlog
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadRecordFormatV1Compat checks that a record written in FormatV1's
+// header-less layout still reads back correctly, the way an existing
+// segment from before FormatV2 was introduced must.
+func TestReadRecordFormatV1Compat(t *testing.T) {
+	dir := t.TempDir()
+	fd, err := newSegmentFile(dir, 1)
+	assert.Nil(t, err)
+
+	seg := &segment{fid: 1, fd: fd, format: FormatV1, writeAt: fileHeaderSize}
+	key, value := []byte("hello"), []byte("world")
+	_, offset, _, err := seg.writeRecord(recordFinal, 0, 0, key, value)
+	assert.Nil(t, err)
+
+	gotKey, gotValue, tag, _, _, _, err := seg.readRecord(offset)
+	assert.Nil(t, err)
+	assert.Equal(t, key, gotKey)
+	assert.Equal(t, value, gotValue)
+	assert.Equal(t, recordFinal, tag)
+}
+
+func newSegmentFile(dir string, fid uint32) (*os.File, error) {
+	return os.OpenFile(segmentPath(dir, fid), os.O_CREATE|os.O_RDWR, 0644)
+}